@@ -2,6 +2,7 @@
 package rpc2
 
 import (
+	"encoding/json"
 	"errors"
 	"io"
 	"log"
@@ -15,19 +16,20 @@ import (
 // with a single Client, and a Client may be used by
 // multiple goroutines simultaneously.
 type Client struct {
-	mutex      sync.Mutex // protects pending, seq, request
-	sending    sync.Mutex
-	request    Request // temp area used in send()
-	seq        uint64
-	pending    map[uint64]*Call
-	closing    bool
-	shutdown   bool
-	server     bool
-	codec      Codec
-	handlers   map[string]*handler
-	disconnect chan struct{}
-	State      *State // additional information to associate with client
-	blocking   bool   // whether to block request handling
+	mutex        sync.Mutex // protects pending, seq, request
+	sending      sync.Mutex
+	request      Request // temp area used in send()
+	seq          uint64
+	pending      map[uint64]*Call
+	closing      bool
+	shutdown     bool
+	server       bool
+	codec        Codec
+	handlers     map[string]*handler
+	disconnect   chan struct{}
+	State        *State // additional information to associate with client
+	blocking     bool   // whether to block request handling
+	interceptors []Interceptor
 }
 
 // NewClient returns a new Client to handle requests to the
@@ -129,18 +131,25 @@ func (c *Client) handleRequest(req Request, method *handler, argv reflect.Value)
 	// Invoke the method, providing a new value for the reply.
 	replyv := reflect.New(method.replyType.Elem())
 
-	returnValues := method.fn.Call([]reflect.Value{reflect.ValueOf(c), argv, replyv})
+	ctx := &CallContext{Method: req.Method, Seq: req.Seq, Client: c, State: c.State}
+	ctx.Params, _ = json.Marshal(argv.Interface())
+
+	err := chainInterceptors(c.interceptors, ctx, func() error {
+		returnValues := method.fn.Call([]reflect.Value{reflect.ValueOf(c), argv, replyv})
+		if errInter := returnValues[0].Interface(); errInter != nil {
+			return errInter.(error)
+		}
+		return nil
+	})()
 
 	// Do not send response if request is a notification.
 	if req.Seq == 0 {
 		return
 	}
 
-	// The return value for the method is an error.
-	errInter := returnValues[0].Interface()
 	errmsg := ""
-	if errInter != nil {
-		errmsg = errInter.(error).Error()
+	if err != nil {
+		errmsg = err.Error()
 	}
 	resp := &Response{
 		Seq:   req.Seq,
@@ -268,12 +277,19 @@ func (c *Client) Go(method string, args interface{}, reply interface{}, done cha
 
 // Call invokes the named function, waits for it to complete or timeout time, and returns its error status.
 func (c *Client) Call(method string, args interface{}, reply interface{}) error {
-	select {
-	case call := <-c.Go(method, args, reply, make(chan *Call, 1)).Done:
-		return call.Error
-	case <-time.After(time.Second * 5):
-		return errors.New("timeout to response")
-	}
+	ctx := &CallContext{Method: method, Client: c, State: c.State}
+	ctx.Params, _ = json.Marshal(args)
+
+	return chainInterceptors(c.interceptors, ctx, func() error {
+		pending := c.Go(method, args, reply, make(chan *Call, 1))
+		ctx.Seq = pending.Seq
+		select {
+		case call := <-pending.Done:
+			return call.Error
+		case <-time.After(time.Second * 5):
+			return errors.New("timeout to response")
+		}
+	})()
 }
 
 func (call *Call) done() {
@@ -305,6 +321,7 @@ type Call struct {
 	Reply  interface{} // The reply from the function (*struct).
 	Error  error       // After completion, the error status.
 	Done   chan *Call  // Strobes when call is complete.
+	Seq    uint64      // Sequence number assigned by send(), for Interceptor use.
 }
 
 func (c *Client) send(call *Call) {
@@ -324,6 +341,8 @@ func (c *Client) send(call *Call) {
 	c.pending[seq] = call
 	c.mutex.Unlock()
 
+	call.Seq = seq
+
 	// Encode and send the request.
 	c.request.Seq = seq
 	c.request.Method = call.Method
@@ -342,16 +361,21 @@ func (c *Client) send(call *Call) {
 
 // Notify sends a request to the receiver but does not wait for a return value.
 func (c *Client) Notify(method string, args interface{}) error {
-	c.sending.Lock()
-	c.mutex.Lock()
-	defer c.sending.Unlock()
-	defer c.mutex.Unlock()
+	ctx := &CallContext{Method: method, Client: c, State: c.State}
+	ctx.Params, _ = json.Marshal(args)
 
-	if c.shutdown || c.closing {
-		return ErrShutdown
-	}
+	return chainInterceptors(c.interceptors, ctx, func() error {
+		c.sending.Lock()
+		c.mutex.Lock()
+		defer c.sending.Unlock()
+		defer c.mutex.Unlock()
+
+		if c.shutdown || c.closing {
+			return ErrShutdown
+		}
 
-	c.request.Seq = 0
-	c.request.Method = method
-	return c.codec.WriteRequest(&c.request, args)
+		c.request.Seq = 0
+		c.request.Method = method
+		return c.codec.WriteRequest(&c.request, args)
+	})()
 }
@@ -0,0 +1,52 @@
+package rpc2
+
+import "encoding/json"
+
+/*
+Error - structured JSON-RPC 2.0 error.
+
+Handlers may return *Error instead of a plain error to control the
+code/message/data surfaced to the caller. Codecs that understand
+structured errors (see stratumrpc) recover the fields via ParseError
+instead of guessing at the shape of a plain error string.
+*/
+type Error struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+// wireError is the JSON encoding of an Error. marker tags it so
+// ParseError can tell it apart from a plain handler error whose text
+// happens to look like a JSON object.
+type wireError struct {
+	Marker  string      `json:"$rpc2Error"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+const wireErrorMarker = "rpc2.Error"
+
+// Error implements the error interface. It encodes the receiver as JSON
+// so ParseError can recover Code/Message/Data losslessly instead of
+// parsing free-form text.
+func (e *Error) Error() string {
+	b, err := json.Marshal(wireError{Marker: wireErrorMarker, Code: e.Code, Message: e.Message, Data: e.Data})
+	if err != nil {
+		return e.Message
+	}
+	return string(b)
+}
+
+// ParseError recovers the *Error encoded by (*Error).Error, if s is in
+// fact one. It reports ok=false for anything else, including a plain
+// error whose text happens to look like a JSON object, so a codec can
+// fall back to its legacy handling of that case.
+func ParseError(s string) (e *Error, ok bool) {
+	var w wireError
+	if err := json.Unmarshal([]byte(s), &w); err != nil || w.Marker != wireErrorMarker {
+		return nil, false
+	}
+	return &Error{Code: w.Code, Message: w.Message, Data: w.Data}, true
+}
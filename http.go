@@ -0,0 +1,154 @@
+package rpc2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HTTPHandler adapts a Server to http.Handler. Each request gets a
+// short-lived Codec of its own, built by NewCodec from a ReadWriteCloser
+// bound to the request body and the response writer, so the same
+// Server.Handle dispatch used for persistent connections also answers
+// one-shot HTTP requests. NewCodec is left to the caller (e.g.
+// stratumrpc.NewStratumCodec) so ServeHTTP stays agnostic of the wire
+// format, batches included.
+type HTTPHandler struct {
+	Server   *Server
+	NewCodec func(io.ReadWriteCloser) Codec
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	conn := &httpServerConn{body: r.Body, w: w}
+	h.Server.ServeCodec(h.NewCodec(conn))
+}
+
+// httpServerConn adapts a single HTTP request/response pair into the
+// io.ReadWriteCloser a Codec expects. Reads drain the request body;
+// writes go straight to the response writer. Close is a no-op: closing
+// the body is http.Server's job once the handler returns.
+type httpServerConn struct {
+	body io.ReadCloser
+	w    io.Writer
+}
+
+func (c *httpServerConn) Read(p []byte) (int, error)  { return c.body.Read(p) }
+func (c *httpServerConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+func (c *httpServerConn) Close() error                { return nil }
+
+// httpClients caches one *http.Client per URL so repeated DialHTTP calls
+// to the same URL share its keep-alive connection pool instead of each
+// opening a fresh http.Transport. Entries live for the process lifetime;
+// callers that dial a bounded, stable set of URLs (the expected use here)
+// never notice, but dialing many distinct one-off URLs would grow this
+// unbounded.
+var httpClients sync.Map // url string -> *http.Client
+
+// DialHTTP returns a Client that issues one HTTP POST per Call/Notify.
+// newCodec encodes/decodes the request and response bodies (e.g.
+// stratumrpc.NewStratumCodec), so the same wire format used over a raw
+// TCP connection works here too, batches included. Because HTTP requests
+// can't carry calls initiated by the server, handlers registered via
+// Client.Handle are never invoked on a Client dialed this way.
+func DialHTTP(url string, newCodec func(io.ReadWriteCloser) Codec) *Client {
+	client, _ := httpClients.LoadOrStore(url, &http.Client{})
+	conn := newHTTPRoundTrip(client.(*http.Client), url)
+	return NewClientWithCodec(newCodec(conn))
+}
+
+// httpRoundTrip is the io.ReadWriteCloser behind DialHTTP: each Write
+// performs one POST and queues its response body; each Read drains the
+// oldest queued body before waiting for the next one. The queue exists
+// because rpc2.Client's read loop and write path run independently, so a
+// second Call's Write can happen before the first Call's Read does. A
+// Notify carries no id, so the server writes nothing back; Write detects
+// that empty body by its Content-Length and discards it instead of
+// queueing it, since Read has no way to tell an empty response from a
+// pending one.
+type httpRoundTrip struct {
+	client *http.Client
+	url    string
+
+	responses chan io.ReadCloser
+	done      chan struct{}
+	closeOnce sync.Once
+	current   io.ReadCloser
+}
+
+func newHTTPRoundTrip(client *http.Client, url string) *httpRoundTrip {
+	return &httpRoundTrip{
+		client:    client,
+		url:       url,
+		responses: make(chan io.ReadCloser, 64),
+		done:      make(chan struct{}),
+	}
+}
+
+func (rt *httpRoundTrip) Write(p []byte) (int, error) {
+	resp, err := rt.client.Post(rt.url, "application/json", bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Drain before closing so http.Transport can reuse the connection
+		// instead of tearing it down.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return 0, fmt.Errorf("rpc2: %s: unexpected status %s", rt.url, resp.Status)
+	}
+	if resp.ContentLength == 0 {
+		resp.Body.Close()
+		return len(p), nil
+	}
+
+	select {
+	case rt.responses <- resp.Body:
+		return len(p), nil
+	case <-rt.done:
+		resp.Body.Close()
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (rt *httpRoundTrip) Read(p []byte) (int, error) {
+	for {
+		if rt.current == nil {
+			select {
+			case body := <-rt.responses:
+				rt.current = body
+			case <-rt.done:
+				return 0, io.EOF
+			}
+		}
+		n, err := rt.current.Read(p)
+		if err == io.EOF {
+			rt.current.Close()
+			rt.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (rt *httpRoundTrip) Close() error {
+	rt.closeOnce.Do(func() { close(rt.done) })
+	for {
+		select {
+		case body := <-rt.responses:
+			body.Close()
+		default:
+			if rt.current != nil {
+				rt.current.Close()
+				rt.current = nil
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package rpc2
+
+import "encoding/json"
+
+/*
+Interceptor wraps one incoming request dispatch or one outgoing Call or
+Notify. It calls next to continue the chain down to the handler or the RPC
+itself, or returns early to short-circuit it; either way its return value
+becomes the error the caller (Server's dispatch loop, or Client.Call/Notify)
+sees. This is the extension point cross-cutting features hook into without
+editing individual handlers: structured logging of every mining.submit,
+Prometheus latency/error-code metrics, per-session rate limiting, auth
+checks on mining.authorize, panic recovery.
+*/
+type Interceptor func(ctx *CallContext, next func() error) error
+
+/*
+CallContext describes one request passing through an Interceptor chain,
+whether it's an incoming request about to be dispatched to a handler or an
+outgoing Call or Notify about to be sent.
+*/
+type CallContext struct {
+	// Method is the RPC method name, e.g. "mining.submit".
+	Method string
+
+	// Params is a JSON encoding of the request's arguments, taken before
+	// dispatch so an Interceptor can inspect them (e.g. a per-session
+	// rate limiter reading the submitted share) with Bind. The handler or
+	// outgoing call still decodes/encodes its own typed argument
+	// independently; Params is only ever read here, never consumed.
+	Params json.RawMessage
+
+	// Seq is the request's sequence number: the codec seq for an
+	// incoming request, or the one Client assigns an outgoing Call. Zero
+	// for an outgoing Notify, which carries no id. For an outgoing Call,
+	// Seq is only populated once next has been invoked, so an
+	// Interceptor that reads it must do so after calling next, not
+	// before.
+	Seq uint64
+
+	// Client is the connection the request arrived on or is being sent
+	// over.
+	Client *Client
+
+	// State is Client's connection state, exposed here so an Interceptor
+	// doesn't need to fish it out of Client itself.
+	State *State
+}
+
+/*
+Bind decodes ctx.Params into v. It leaves v untouched and returns nil if
+Params is empty, the same convention ReadRequestBody uses for a
+notification with no arguments.
+*/
+func (ctx *CallContext) Bind(v interface{}) error {
+	if len(ctx.Params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(ctx.Params, v)
+}
+
+/*
+chainInterceptors composes interceptors around final into the single
+func() error that a dispatch site invokes. Interceptors run in the order
+given: the first one registered is outermost, seeing the request before
+any other interceptor and the result after every other one; the last is
+innermost, right next to final.
+*/
+func chainInterceptors(interceptors []Interceptor, ctx *CallContext, final func() error) func() error {
+	call := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], call
+		call = func() error { return interceptor(ctx, next) }
+	}
+	return call
+}
+
+/*
+Use appends interceptors to the Server's chain. They wrap every incoming
+request's dispatch to its handler, in the order given: the first one
+registered is outermost.
+*/
+func (s *Server) Use(interceptors ...Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+/*
+Use appends interceptors to the Client's chain. They wrap every outgoing
+Call and Notify, in the order given: the first one registered is
+outermost.
+*/
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
@@ -0,0 +1,89 @@
+package rpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each LoadAll/Save round trip so a slow or
+// unreachable Redis can't stall the connection hydrating it.
+const redisTimeout = 5 * time.Second
+
+/*
+RedisStore - Store backed by Redis, keyed by mining session ID. Get, Set,
+Delete and Keys operate on an in-memory shadow held for the life of one
+connection; LoadAll and Save move that shadow to and from Redis under
+keyPrefix+sessionID, which is the point a pool operator can move a miner
+between workers without losing its session parameters.
+*/
+type RedisStore struct {
+	client    *redis.Client
+	keyPrefix string
+	memoryStore
+}
+
+/*
+NewRedisStore - Redis-backed Store initialization. keyPrefix namespaces
+the Redis keys this store reads and writes, e.g. "rpc2:session:".
+*/
+func NewRedisStore(client *redis.Client, keyPrefix string) *RedisStore {
+	return &RedisStore{client: client, keyPrefix: keyPrefix, memoryStore: *newMemoryStore()}
+}
+
+func (s *RedisStore) key(sessionID string) string {
+	return s.keyPrefix + sessionID
+}
+
+/*
+LoadAll - replace the in-memory shadow with the session stored in Redis
+under sessionID. A sessionID with nothing saved yet leaves the shadow
+empty rather than erroring, and so does a Redis round trip through JSON:
+a value Set as an int comes back from Get as a float64, same as any other
+map[string]interface{} decoded from JSON.
+*/
+func (s *RedisStore) LoadAll(sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	raw, err := s.client.Get(ctx, s.key(sessionID)).Result()
+	if err == redis.Nil {
+		s.m.Lock()
+		s.data = make(map[string]interface{})
+		s.m.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("rpc2: redis state: load %s: %w", sessionID, err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return fmt.Errorf("rpc2: redis state: decode %s: %w", sessionID, err)
+	}
+	s.m.Lock()
+	s.data = data
+	s.m.Unlock()
+	return nil
+}
+
+/*
+Save - persist the in-memory shadow to Redis under sessionID.
+*/
+func (s *RedisStore) Save(sessionID string) error {
+	s.m.RLock()
+	raw, err := json.Marshal(s.data)
+	s.m.RUnlock()
+	if err != nil {
+		return fmt.Errorf("rpc2: redis state: encode %s: %w", sessionID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := s.client.Set(ctx, s.key(sessionID), raw, 0).Err(); err != nil {
+		return fmt.Errorf("rpc2: redis state: save %s: %w", sessionID, err)
+	}
+	return nil
+}
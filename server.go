@@ -23,8 +23,11 @@ const (
 
 // Server responds to RPC requests made by Client.
 type Server struct {
-	handlers map[string]*handler
-	eventHub *hub.Hub
+	handlers         map[string]*handler
+	eventHub         *hub.Hub
+	interceptors     []Interceptor
+	newStore         func() Store
+	sessionExtractor SessionExtractor
 }
 
 type handler struct {
@@ -158,9 +161,15 @@ func (s *Server) ServeConn(conn io.ReadWriteCloser) {
 }
 
 // ServeCodec is like ServeConn but uses the specified codec to
-// decode requests and encode responses.
+// decode requests and encode responses. The connection's State is backed
+// by the in-memory default, or by a fresh Store from UseSessionStore if
+// one was configured.
 func (s *Server) ServeCodec(codec Codec) {
-	s.ServeCodecWithState(codec, NewState())
+	state := NewState()
+	if s.newStore != nil {
+		state = NewStateWithStore(s.newStore())
+	}
+	s.ServeCodecWithState(codec, state)
 }
 
 // ServeCodecWithState is like ServeCodec but also gives the ability to
@@ -173,6 +182,10 @@ func (s *Server) ServeCodecWithState(codec Codec, state *State) {
 	c.server = true
 	c.handlers = s.handlers
 	c.State = state
+	c.interceptors = append([]Interceptor(nil), s.interceptors...)
+	if s.sessionExtractor != nil {
+		c.interceptors = append(c.interceptors, sessionHydrateInterceptor(s.sessionExtractor))
+	}
 
 	s.eventHub.Publish(connectionEvent{c})
 	c.Run()
@@ -1,37 +1,197 @@
 package rpc2
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 /*
-State - state of connection.
+Store - pluggable backend for State.
+
+Get/Set/Delete/Keys back a single connection's variables. LoadAll/Save are
+optional, declared separately on Persister: a Store that implements it can
+hydrate State from, and persist it to, a session identified by a session
+ID, so a miner that reconnects to a different pool worker keeps its
+session parameters instead of starting over.
+*/
+type Store interface {
+	Get(key string) (value interface{}, ok bool)
+	Set(key string, value interface{})
+	Delete(key string)
+	Keys() []string
+}
+
+/*
+Persister - optional Store capability to hydrate/persist a whole session
+by ID, e.g. the extranonce1 and difficulty a Stratum miner negotiated
+before it dropped and reconnected to a different pool worker.
+*/
+type Persister interface {
+	LoadAll(sessionID string) error
+	Save(sessionID string) error
+}
+
+/*
+SessionExtractor resolves the session ID an incoming connection is
+resuming, e.g. the SessionID a mining.subscribe call asked to resume. It
+is consulted once per incoming request, before that request's handler
+runs, until it returns ok; register it with Server.UseSessionStore to
+have the Server hydrate State from the connection's Store as soon as its
+session ID is known, instead of calling State.LoadAll by hand.
+*/
+type SessionExtractor func(client *Client) (sessionID string, ok bool)
+
+/*
+UseSessionStore configures how Server backs a connection's State: newStore
+returns a fresh Store for each accepted connection (e.g. a *RedisStore
+sharing one redis.Client but with its own in-memory shadow), and extractor
+resolves the session ID to hydrate that Store from. A connection's State
+starts out empty, the same as NewState, until extractor resolves its
+session ID - typically from the SessionID field of a mining.subscribe
+call - at which point State is loaded from newStore's Store under that
+ID. This is what lets a miner reconnect to a different pool worker
+without losing the session parameters it already negotiated.
+*/
+func (s *Server) UseSessionStore(newStore func() Store, extractor SessionExtractor) {
+	s.newStore = newStore
+	s.sessionExtractor = extractor
+}
+
+/*
+sessionHydrateInterceptor returns an Interceptor, fresh per connection,
+that calls extractor on every incoming request until it resolves a
+session ID, then hydrates ctx.State from its Store under that ID exactly
+once. Requests handled concurrently with the one that resolves the
+session ID may still see State as it was before hydration; the Stratum
+handshake this targets (mining.subscribe before any other call) doesn't
+hit that race in practice.
+*/
+func sessionHydrateInterceptor(extractor SessionExtractor) Interceptor {
+	var hydrated int32
+	return func(ctx *CallContext, next func() error) error {
+		if atomic.LoadInt32(&hydrated) == 0 {
+			if sessionID, ok := extractor(ctx.Client); ok && atomic.CompareAndSwapInt32(&hydrated, 0, 1) {
+				if _, err := ctx.State.LoadAll(sessionID); err != nil {
+					debugln("rpc2: error hydrating session", sessionID, ":", err.Error())
+				}
+			}
+		}
+		return next()
+	}
+}
+
+/*
+State - state of connection, backed by a pluggable Store.
 */
 type State struct {
-	store map[string]interface{}
-	m     sync.RWMutex
+	store Store
 }
 
 /*
-NewState - connection state initialization.
+NewState - connection state initialization, backed by an in-memory map.
 */
 func NewState() *State {
-	return &State{store: make(map[string]interface{})}
+	return &State{store: newMemoryStore()}
+}
+
+/*
+NewStateWithStore - connection state initialization backed by store, e.g.
+a Redis-backed store shared across pool workers.
+*/
+func NewStateWithStore(store Store) *State {
+	return &State{store: store}
 }
 
 /*
 Get - get connection state variable.
 */
 func (s *State) Get(key string) (value interface{}, ok bool) {
-	s.m.RLock()
-	value, ok = s.store[key]
-	s.m.RUnlock()
-	return
+	return s.store.Get(key)
 }
 
 /*
 Set - set connection state variable.
 */
 func (s *State) Set(key string, value interface{}) {
+	s.store.Set(key, value)
+}
+
+/*
+Delete - delete connection state variable.
+*/
+func (s *State) Delete(key string) {
+	s.store.Delete(key)
+}
+
+/*
+Keys - list connection state variable names.
+*/
+func (s *State) Keys() []string {
+	return s.store.Keys()
+}
+
+/*
+LoadAll - hydrate State from its Store for sessionID. ok reports whether
+the Store implements Persister at all; the in-memory default from
+NewState does not, since it has nothing to load.
+*/
+func (s *State) LoadAll(sessionID string) (ok bool, err error) {
+	p, ok := s.store.(Persister)
+	if !ok {
+		return false, nil
+	}
+	return true, p.LoadAll(sessionID)
+}
+
+/*
+Save - persist State to its Store under sessionID. ok reports whether the
+Store implements Persister at all.
+*/
+func (s *State) Save(sessionID string) (ok bool, err error) {
+	p, ok := s.store.(Persister)
+	if !ok {
+		return false, nil
+	}
+	return true, p.Save(sessionID)
+}
+
+/*
+memoryStore - default in-memory Store used by NewState.
+*/
+type memoryStore struct {
+	data map[string]interface{}
+	m    sync.RWMutex
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: make(map[string]interface{})}
+}
+
+func (s *memoryStore) Get(key string) (value interface{}, ok bool) {
+	s.m.RLock()
+	value, ok = s.data[key]
+	s.m.RUnlock()
+	return
+}
+
+func (s *memoryStore) Set(key string, value interface{}) {
 	s.m.Lock()
-	s.store[key] = value
+	s.data[key] = value
 	s.m.Unlock()
 }
+
+func (s *memoryStore) Delete(key string) {
+	s.m.Lock()
+	delete(s.data, key)
+	s.m.Unlock()
+}
+
+func (s *memoryStore) Keys() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
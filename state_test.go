@@ -0,0 +1,137 @@
+package rpc2
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var errLoadAllFailed = errors.New("redis: connection refused")
+
+type fakePersistentStore struct {
+	memoryStore
+	loadedSessionID string
+	loadCalls       int
+	loadErr         error
+}
+
+func (s *fakePersistentStore) LoadAll(sessionID string) error {
+	s.loadCalls++
+	s.loadedSessionID = sessionID
+	if s.loadErr != nil {
+		return s.loadErr
+	}
+	s.Set("hydrated", sessionID)
+	return nil
+}
+
+func (s *fakePersistentStore) Save(sessionID string) error { return nil }
+
+func newFakePersistentStore() *fakePersistentStore {
+	return &fakePersistentStore{memoryStore: *newMemoryStore()}
+}
+
+func TestSessionHydrateInterceptorLoadsOnceSessionIDResolves(t *testing.T) {
+	store := newFakePersistentStore()
+	state := NewStateWithStore(store)
+
+	const wantSessionID = "session-123"
+	extractor := func(client *Client) (string, bool) { return wantSessionID, true }
+	interceptor := sessionHydrateInterceptor(extractor)
+
+	ctx := &CallContext{State: state}
+	for i := 0; i < 3; i++ {
+		if err := interceptor(ctx, func() error { return nil }); err != nil {
+			t.Fatalf("interceptor: %v", err)
+		}
+	}
+
+	if store.loadCalls != 1 {
+		t.Fatalf("LoadAll called %d times, want 1", store.loadCalls)
+	}
+	if store.loadedSessionID != wantSessionID {
+		t.Fatalf("loaded session %q, want %q", store.loadedSessionID, wantSessionID)
+	}
+}
+
+func TestSessionHydrateInterceptorSkipsUntilExtractorResolves(t *testing.T) {
+	store := newFakePersistentStore()
+	state := NewStateWithStore(store)
+
+	var resolved bool
+	extractor := func(client *Client) (string, bool) {
+		if !resolved {
+			return "", false
+		}
+		return "session-456", true
+	}
+	interceptor := sessionHydrateInterceptor(extractor)
+	ctx := &CallContext{State: state}
+
+	if err := interceptor(ctx, func() error { return nil }); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if store.loadCalls != 0 {
+		t.Fatalf("LoadAll called before extractor resolved a session ID")
+	}
+
+	resolved = true
+	if err := interceptor(ctx, func() error { return nil }); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if store.loadCalls != 1 {
+		t.Fatalf("LoadAll called %d times, want 1", store.loadCalls)
+	}
+}
+
+func TestSessionHydrateInterceptorLogsLoadAllError(t *testing.T) {
+	store := newFakePersistentStore()
+	store.loadErr = errLoadAllFailed
+	state := NewStateWithStore(store)
+
+	extractor := func(client *Client) (string, bool) { return "session-bad", true }
+	interceptor := sessionHydrateInterceptor(extractor)
+	ctx := &CallContext{State: state}
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	if err := interceptor(ctx, func() error { return nil }); err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	if store.loadCalls != 1 {
+		t.Fatalf("LoadAll called %d times, want 1", store.loadCalls)
+	}
+	if !strings.Contains(logged.String(), errLoadAllFailed.Error()) {
+		t.Fatalf("expected LoadAll's error to be logged, got %q", logged.String())
+	}
+}
+
+func TestSessionHydrateInterceptorConcurrentRequestsHydrateOnce(t *testing.T) {
+	store := newFakePersistentStore()
+	state := NewStateWithStore(store)
+
+	extractor := func(client *Client) (string, bool) { return "session-789", true }
+	interceptor := sessionHydrateInterceptor(extractor)
+	ctx := &CallContext{State: state}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			interceptor(ctx, func() error { return nil })
+		}()
+	}
+	wg.Wait()
+
+	if store.loadCalls != 1 {
+		t.Fatalf("LoadAll called %d times under concurrent dispatch, want 1", store.loadCalls)
+	}
+}
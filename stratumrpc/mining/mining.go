@@ -0,0 +1,337 @@
+// Package mining defines typed Go values for the canonical Stratum V1
+// mining.* messages and thin wrappers around rpc2.Server and rpc2.Client
+// that marshal them to and from the positional []interface{} wire form
+// stratumrpc.StratumCodec already speaks. Callers index into a struct
+// instead of a slice; the field order each message needs on the wire is
+// fixed by marshal/unmarshal below and never exposed to the caller.
+//
+// Calls originate from the miner: Subscribe, Authorize and Submit run
+// client-side, with HandleSubscribe, HandleAuthorize and HandleSubmit
+// registering the matching server handler. Notify, SetDifficulty and
+// Reconnect push server-initiated work to an already-connected client, with
+// HandleNotify, HandleSetDifficulty and HandleReconnect registering the
+// matching client handler.
+package mining
+
+import (
+	"fmt"
+
+	rpc2 "github.com/miningmeter/rpc2"
+)
+
+// toInt converts a decoded JSON number (always float64) or a literal int to
+// an int, reporting ok=false for anything else.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// SubscribeReq is the mining.subscribe call params: the mining software's
+// user agent and, when resuming a previous session, its SessionID.
+type SubscribeReq struct {
+	UserAgent string
+	SessionID string
+}
+
+func (r SubscribeReq) marshal() []interface{} {
+	return []interface{}{r.UserAgent, r.SessionID}
+}
+
+func unmarshalSubscribeReq(args []interface{}) (SubscribeReq, error) {
+	if len(args) < 1 {
+		return SubscribeReq{}, fmt.Errorf("mining.subscribe: expected at least 1 param, got %d", len(args))
+	}
+	req := SubscribeReq{}
+	req.UserAgent, _ = args[0].(string)
+	if len(args) > 1 {
+		req.SessionID, _ = args[1].(string)
+	}
+	return req, nil
+}
+
+// SubscribeResult is the mining.subscribe reply: the session ID plus the
+// extranonce1/extranonce2Size the client folds into every mining.submit.
+type SubscribeResult struct {
+	SessionID       string
+	Extranonce1     string
+	Extranonce2Size int
+}
+
+func (r SubscribeResult) marshal() []interface{} {
+	return []interface{}{r.SessionID, r.Extranonce1, r.Extranonce2Size}
+}
+
+func unmarshalSubscribeResult(args []interface{}) (SubscribeResult, error) {
+	if len(args) < 3 {
+		return SubscribeResult{}, fmt.Errorf("mining.subscribe result: expected 3 params, got %d", len(args))
+	}
+	res := SubscribeResult{}
+	res.SessionID, _ = args[0].(string)
+	res.Extranonce1, _ = args[1].(string)
+	res.Extranonce2Size, _ = toInt(args[2])
+	return res, nil
+}
+
+// HandleSubscribe registers fn as the server's mining.subscribe handler,
+// decoding the positional args into a SubscribeReq and encoding fn's
+// SubscribeResult back into the positional result the client expects.
+func HandleSubscribe(server *rpc2.Server, fn func(client *rpc2.Client, req SubscribeReq) (SubscribeResult, error)) {
+	server.Handle("mining.subscribe", func(client *rpc2.Client, args []interface{}, result *[]interface{}) error {
+		req, err := unmarshalSubscribeReq(args)
+		if err != nil {
+			return err
+		}
+		res, err := fn(client, req)
+		if err != nil {
+			return err
+		}
+		*result = res.marshal()
+		return nil
+	})
+}
+
+// Subscribe calls mining.subscribe on the server and decodes the result.
+func Subscribe(client *rpc2.Client, req SubscribeReq) (SubscribeResult, error) {
+	var result []interface{}
+	if err := client.Call("mining.subscribe", req.marshal(), &result); err != nil {
+		return SubscribeResult{}, err
+	}
+	return unmarshalSubscribeResult(result)
+}
+
+// AuthorizeReq is the mining.authorize call params.
+type AuthorizeReq struct {
+	Username string
+	Password string
+}
+
+func (r AuthorizeReq) marshal() []interface{} {
+	return []interface{}{r.Username, r.Password}
+}
+
+func unmarshalAuthorizeReq(args []interface{}) (AuthorizeReq, error) {
+	if len(args) < 2 {
+		return AuthorizeReq{}, fmt.Errorf("mining.authorize: expected 2 params, got %d", len(args))
+	}
+	req := AuthorizeReq{}
+	req.Username, _ = args[0].(string)
+	req.Password, _ = args[1].(string)
+	return req, nil
+}
+
+// HandleAuthorize registers fn as the server's mining.authorize handler.
+func HandleAuthorize(server *rpc2.Server, fn func(client *rpc2.Client, req AuthorizeReq) (bool, error)) {
+	server.Handle("mining.authorize", func(client *rpc2.Client, args []interface{}, result *bool) error {
+		req, err := unmarshalAuthorizeReq(args)
+		if err != nil {
+			return err
+		}
+		ok, err := fn(client, req)
+		if err != nil {
+			return err
+		}
+		*result = ok
+		return nil
+	})
+}
+
+// Authorize calls mining.authorize on the server.
+func Authorize(client *rpc2.Client, req AuthorizeReq) (bool, error) {
+	var result bool
+	err := client.Call("mining.authorize", req.marshal(), &result)
+	return result, err
+}
+
+// SubmitParams is the mining.submit call params: a solved share for the
+// given job, identified by the worker's extranonce2/ntime/nonce choices.
+type SubmitParams struct {
+	Username    string
+	JobID       string
+	Extranonce2 string
+	NTime       string
+	Nonce       string
+}
+
+func (p SubmitParams) marshal() []interface{} {
+	return []interface{}{p.Username, p.JobID, p.Extranonce2, p.NTime, p.Nonce}
+}
+
+func unmarshalSubmitParams(args []interface{}) (SubmitParams, error) {
+	if len(args) < 5 {
+		return SubmitParams{}, fmt.Errorf("mining.submit: expected 5 params, got %d", len(args))
+	}
+	p := SubmitParams{}
+	p.Username, _ = args[0].(string)
+	p.JobID, _ = args[1].(string)
+	p.Extranonce2, _ = args[2].(string)
+	p.NTime, _ = args[3].(string)
+	p.Nonce, _ = args[4].(string)
+	return p, nil
+}
+
+// HandleSubmit registers fn as the server's mining.submit handler.
+func HandleSubmit(server *rpc2.Server, fn func(client *rpc2.Client, params SubmitParams) (bool, error)) {
+	server.Handle("mining.submit", func(client *rpc2.Client, args []interface{}, result *bool) error {
+		params, err := unmarshalSubmitParams(args)
+		if err != nil {
+			return err
+		}
+		ok, err := fn(client, params)
+		if err != nil {
+			return err
+		}
+		*result = ok
+		return nil
+	})
+}
+
+// Submit calls mining.submit on the server.
+func Submit(client *rpc2.Client, params SubmitParams) (bool, error) {
+	var result bool
+	err := client.Call("mining.submit", params.marshal(), &result)
+	return result, err
+}
+
+// NotifyParams is the mining.notify params announcing a new job. Field
+// order matches the Stratum V1 wire positions exactly; unlike the other
+// messages here, a client must not reorder them since coinb1/coinb2 and
+// merkleBranches only make sense assembled in this order.
+type NotifyParams struct {
+	JobID          string
+	PrevHash       string
+	Coinb1         string
+	Coinb2         string
+	MerkleBranches []string
+	Version        string
+	NBits          string
+	NTime          string
+	CleanJobs      bool
+}
+
+func (p NotifyParams) marshal() []interface{} {
+	branches := make([]interface{}, len(p.MerkleBranches))
+	for i, b := range p.MerkleBranches {
+		branches[i] = b
+	}
+	return []interface{}{p.JobID, p.PrevHash, p.Coinb1, p.Coinb2, branches, p.Version, p.NBits, p.NTime, p.CleanJobs}
+}
+
+func unmarshalNotifyParams(args []interface{}) (NotifyParams, error) {
+	if len(args) < 9 {
+		return NotifyParams{}, fmt.Errorf("mining.notify: expected 9 params, got %d", len(args))
+	}
+	p := NotifyParams{}
+	p.JobID, _ = args[0].(string)
+	p.PrevHash, _ = args[1].(string)
+	p.Coinb1, _ = args[2].(string)
+	p.Coinb2, _ = args[3].(string)
+	if raw, ok := args[4].([]interface{}); ok {
+		p.MerkleBranches = make([]string, len(raw))
+		for i, b := range raw {
+			p.MerkleBranches[i], _ = b.(string)
+		}
+	}
+	p.Version, _ = args[5].(string)
+	p.NBits, _ = args[6].(string)
+	p.NTime, _ = args[7].(string)
+	p.CleanJobs, _ = args[8].(bool)
+	return p, nil
+}
+
+// Notify pushes a mining.notify notification announcing a new job to client.
+func Notify(client *rpc2.Client, params NotifyParams) error {
+	return client.Notify("mining.notify", params.marshal())
+}
+
+// HandleNotify registers fn as the client's mining.notify handler.
+func HandleNotify(client *rpc2.Client, fn func(client *rpc2.Client, params NotifyParams)) {
+	client.Handle("mining.notify", func(c *rpc2.Client, args []interface{}, _ *interface{}) error {
+		params, err := unmarshalNotifyParams(args)
+		if err != nil {
+			return err
+		}
+		fn(c, params)
+		return nil
+	})
+}
+
+// SetDifficultyParams is the mining.set_difficulty params.
+type SetDifficultyParams struct {
+	Difficulty float64
+}
+
+func (p SetDifficultyParams) marshal() []interface{} {
+	return []interface{}{p.Difficulty}
+}
+
+func unmarshalSetDifficultyParams(args []interface{}) (SetDifficultyParams, error) {
+	if len(args) < 1 {
+		return SetDifficultyParams{}, fmt.Errorf("mining.set_difficulty: expected 1 param, got %d", len(args))
+	}
+	p := SetDifficultyParams{}
+	p.Difficulty, _ = args[0].(float64)
+	return p, nil
+}
+
+// SetDifficulty pushes a mining.set_difficulty notification to client.
+func SetDifficulty(client *rpc2.Client, params SetDifficultyParams) error {
+	return client.Notify("mining.set_difficulty", params.marshal())
+}
+
+// HandleSetDifficulty registers fn as the client's mining.set_difficulty handler.
+func HandleSetDifficulty(client *rpc2.Client, fn func(client *rpc2.Client, params SetDifficultyParams)) {
+	client.Handle("mining.set_difficulty", func(c *rpc2.Client, args []interface{}, _ *interface{}) error {
+		params, err := unmarshalSetDifficultyParams(args)
+		if err != nil {
+			return err
+		}
+		fn(c, params)
+		return nil
+	})
+}
+
+// ReconnectParams is the client.reconnect params: the host/port a client
+// should switch to, and how long to wait before doing so.
+type ReconnectParams struct {
+	Hostname string
+	Port     int
+	WaitTime int
+}
+
+func (p ReconnectParams) marshal() []interface{} {
+	return []interface{}{p.Hostname, p.Port, p.WaitTime}
+}
+
+func unmarshalReconnectParams(args []interface{}) (ReconnectParams, error) {
+	if len(args) < 3 {
+		return ReconnectParams{}, fmt.Errorf("client.reconnect: expected 3 params, got %d", len(args))
+	}
+	p := ReconnectParams{}
+	p.Hostname, _ = args[0].(string)
+	p.Port, _ = toInt(args[1])
+	p.WaitTime, _ = toInt(args[2])
+	return p, nil
+}
+
+// Reconnect pushes a client.reconnect notification to client.
+func Reconnect(client *rpc2.Client, params ReconnectParams) error {
+	return client.Notify("client.reconnect", params.marshal())
+}
+
+// HandleReconnect registers fn as the client's client.reconnect handler.
+func HandleReconnect(client *rpc2.Client, fn func(client *rpc2.Client, params ReconnectParams)) {
+	client.Handle("client.reconnect", func(c *rpc2.Client, args []interface{}, _ *interface{}) error {
+		params, err := unmarshalReconnectParams(args)
+		if err != nil {
+			return err
+		}
+		fn(c, params)
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package mining
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// wireRoundTrip simulates what actually crosses the wire: marshal produces
+// positional []interface{} params, stratumrpc.StratumCodec encodes/decodes
+// those as JSON, and unmarshal receives back whatever encoding/json
+// produces (float64 for numbers, []interface{} for nested arrays) rather
+// than the original Go types.
+func wireRoundTrip(t *testing.T, args []interface{}) []interface{} {
+	t.Helper()
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out []interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out
+}
+
+func TestSubscribeRoundTrip(t *testing.T) {
+	want := SubscribeReq{UserAgent: "cgminer/4.10.0", SessionID: "ab12cd34"}
+	got, err := unmarshalSubscribeReq(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSubscribeResultRoundTrip(t *testing.T) {
+	want := SubscribeResult{SessionID: "ab12cd34", Extranonce1: "deadbeef", Extranonce2Size: 4}
+	got, err := unmarshalSubscribeResult(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAuthorizeRoundTrip(t *testing.T) {
+	want := AuthorizeReq{Username: "worker.1", Password: "x"}
+	got, err := unmarshalAuthorizeReq(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSubmitParamsRoundTrip(t *testing.T) {
+	want := SubmitParams{
+		Username:    "worker.1",
+		JobID:       "job-7",
+		Extranonce2: "0000000a",
+		NTime:       "5d00fa01",
+		Nonce:       "0f12a3b4",
+	}
+	got, err := unmarshalSubmitParams(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestNotifyParamsRoundTrip guards the field order NotifyParams's own doc
+// comment warns about: every field holds a distinct value, so a marshal or
+// unmarshal that transposed two positions (e.g. NBits/NTime) would be
+// caught by this test failing on the wrong field rather than passing by
+// coincidence.
+func TestNotifyParamsRoundTrip(t *testing.T) {
+	want := NotifyParams{
+		JobID:          "job-7",
+		PrevHash:       "prevhash",
+		Coinb1:         "coinb1",
+		Coinb2:         "coinb2",
+		MerkleBranches: []string{"branch-a", "branch-b"},
+		Version:        "version",
+		NBits:          "nbits",
+		NTime:          "ntime",
+		CleanJobs:      true,
+	}
+	got, err := unmarshalNotifyParams(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSetDifficultyParamsRoundTrip(t *testing.T) {
+	want := SetDifficultyParams{Difficulty: 16384.5}
+	got, err := unmarshalSetDifficultyParams(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReconnectParamsRoundTrip(t *testing.T) {
+	want := ReconnectParams{Hostname: "pool.example.com", Port: 3333, WaitTime: 10}
+	got, err := unmarshalReconnectParams(wireRoundTrip(t, want.marshal()))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
@@ -4,27 +4,36 @@
 // Use []interface{} as the type of argument when sending and receiving methods.
 //
 // Positional arguments example:
-// 	server.Handle("add", func(client *rpc2.Client, args []interface{}, result *float64) error {
-// 		*result = args[0].(float64) + args[1].(float64)
-// 		return nil
-// 	})
+//
+//	server.Handle("add", func(client *rpc2.Client, args []interface{}, result *float64) error {
+//		*result = args[0].(float64) + args[1].(float64)
+//		return nil
+//	})
 //
 //	var result float64
-// 	client.Call("add", []interface{}{1, 2}, &result)
+//	client.Call("add", []interface{}{1, 2}, &result)
+//
+// # Batches
 //
+// A client may send a JSON-RPC 2.0 batch: a top-level JSON array holding
+// several request objects. StratumCodec detects the array, feeds the
+// sub-requests to ReadHeader/ReadRequestBody one at a time, and coalesces
+// the matching WriteResponse calls back into a single JSON array.
 package stratumrpc
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	rpc2 "github.com/miningmeter/rpc2"
 )
 
-type stratumCodec struct {
+type StratumCodec struct {
 	dec *json.Decoder // for reading JSON values
 	enc *json.Encoder // for writing JSON values
 	c   io.Closer
@@ -43,16 +52,105 @@ type stratumCodec struct {
 	mutex   sync.Mutex // protects seq, pending
 	pending map[uint64]*json.RawMessage
 	seq     uint64
+
+	encMutex sync.Mutex // serializes all writes to enc: requests, responses, and batch flushes from the timer
+
+	maxBatchSize   int
+	batchFlushWait time.Duration
+
+	queue      []message     // sub-messages of the batch currently being drained
+	queueGroup []*batchGroup // group each queued sub-message belongs to, nil outside a batch
+	batchMu    sync.Mutex    // protects groups
+	groups     map[uint64]*batchGroup
+
+	errorMode ErrorMode
+
+	// structErrMu protects structErrs and structErrOrder: the structured
+	// errors decoded from responses, keyed by the response's sequence
+	// number so a caller reading a given Response can recover the error
+	// that belongs to it instead of whatever ReadHeader decoded last.
+	// Entries are only removed by an explicit StructuredError call or,
+	// for a caller that never makes one, by maxStructErrs eviction below
+	// - without that bound, an error response nobody ever asks about
+	// would sit in the map for the life of the connection.
+	structErrMu    sync.Mutex
+	structErrs     map[uint64]*rpc2.Error
+	structErrOrder []uint64
+}
+
+// maxStructErrs bounds structErrs: once it holds this many unclaimed
+// entries, ReadHeader evicts the oldest to make room for the newest,
+// oldest-sequence-number first since seq only increases within a
+// connection.
+const maxStructErrs = 1024
+
+// ErrorMode selects the wire form StratumCodec uses to serialize a
+// structured rpc2.Error in WriteResponse.
+type ErrorMode int
+
+const (
+	// StratumErrorMode serializes errors as the Stratum-style
+	// [code, message, data] tuple. This is the default, matching the
+	// wire format mining pools have always used.
+	StratumErrorMode ErrorMode = iota
+	// JSONRPC2ErrorMode serializes errors as a JSON-RPC 2.0
+	// {"code":...,"message":...,"data":...} object.
+	JSONRPC2ErrorMode
+)
+
+// WithErrorMode selects the wire form used for error responses written
+// by WriteResponse. Defaults to StratumErrorMode.
+func WithErrorMode(m ErrorMode) Option {
+	return func(c *StratumCodec) { c.errorMode = m }
+}
+
+// batchGroup accumulates the responses for the requests that arrived
+// together in a single JSON-RPC 2.0 batch, so they can be flushed back
+// as a single JSON array.
+type batchGroup struct {
+	expected  int      // number of sibling requests still expecting a response
+	order     []uint64 // request sequence numbers, in the order they appeared in the batch
+	responses map[uint64]serverResponse
+	timer     *time.Timer
+	flushed   bool
+}
+
+// Option configures a StratumCodec returned by NewStratumCodec.
+type Option func(*StratumCodec)
+
+// WithMaxBatchSize caps the number of requests accepted in a single
+// JSON-RPC 2.0 batch. A batch exceeding the limit is rejected with a
+// single "invalid request" error response and the connection is closed,
+// instead of dispatching any of its requests. Zero (the default) leaves
+// batches uncapped.
+func WithMaxBatchSize(n int) Option {
+	return func(c *StratumCodec) { c.maxBatchSize = n }
+}
+
+// WithBatchFlushTimeout bounds how long WriteResponse waits for the rest
+// of a batch's responses before flushing whatever has been collected so
+// far. Zero (the default) disables the deadline: the batch is flushed
+// only once every one of its requests has a response.
+func WithBatchFlushTimeout(d time.Duration) Option {
+	return func(c *StratumCodec) { c.batchFlushWait = d }
 }
 
-// NewStratumCodec returns a new rpc2.Codec using JSON-RPC on conn.
-func NewStratumCodec(conn io.ReadWriteCloser) rpc2.Codec {
-	return &stratumCodec{
-		dec:     json.NewDecoder(conn),
-		enc:     json.NewEncoder(conn),
-		c:       conn,
-		pending: make(map[uint64]*json.RawMessage),
+// NewStratumCodec returns a new rpc2.Codec using JSON-RPC on conn. The
+// concrete type is returned (rather than the rpc2.Codec interface) so
+// callers can reach StructuredError in addition to the Codec methods.
+func NewStratumCodec(conn io.ReadWriteCloser, opts ...Option) *StratumCodec {
+	c := &StratumCodec{
+		dec:        json.NewDecoder(conn),
+		enc:        json.NewEncoder(conn),
+		c:          conn,
+		pending:    make(map[uint64]*json.RawMessage),
+		groups:     make(map[uint64]*batchGroup),
+		structErrs: make(map[uint64]*rpc2.Error),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // serverRequest and clientResponse combined
@@ -88,11 +186,87 @@ type clientRequest struct {
 	ID     *uint64       `json:"id"`
 }
 
-func (c *stratumCodec) ReadHeader(req *rpc2.Request, resp *rpc2.Response) error {
-	c.msg = message{}
-	if err := c.dec.Decode(&c.msg); err != nil {
-		return err
+// rpcError mirrors a structured error on the wire in its JSON-RPC 2.0
+// object form, used by WriteResponse to serialize a *rpc2.Error
+// recovered via rpc2.ParseError.
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+var errBatchTooLarge = errors.New("stratumrpc: batch size exceeds the configured limit")
+var errEmptyBatch = errors.New("stratumrpc: batch must not be empty")
+
+// protocolError builds the wire form of a batch-level protocol error,
+// honoring errorMode the same way WriteResponse does for per-request
+// structured errors.
+func (c *StratumCodec) protocolError(code int, message string) interface{} {
+	if c.errorMode == JSONRPC2ErrorMode {
+		return rpcError{Code: code, Message: message}
 	}
+	return []interface{}{code, message, nil}
+}
+
+func (c *StratumCodec) ReadHeader(req *rpc2.Request, resp *rpc2.Response) error {
+	if len(c.queue) == 0 {
+		var raw json.RawMessage
+		if err := c.dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			var items []json.RawMessage
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				c.writeBatch([]serverResponse{{
+					ID:    &null,
+					Error: c.protocolError(-32600, "invalid request: empty batch"),
+				}})
+				return errEmptyBatch
+			}
+			if c.maxBatchSize > 0 && len(items) > c.maxBatchSize {
+				c.writeBatch([]serverResponse{{
+					ID:    &null,
+					Error: c.protocolError(-32600, "invalid request: batch too large"),
+				}})
+				return errBatchTooLarge
+			}
+			queue := make([]message, len(items))
+			expected := 0
+			for i, item := range items {
+				queue[i] = message{}
+				if err := json.Unmarshal(item, &queue[i]); err != nil {
+					return err
+				}
+				if queue[i].Method != "" && queue[i].ID != nil {
+					expected++
+				}
+			}
+			c.queue = queue
+			c.queueGroup = make([]*batchGroup, len(queue))
+			if expected > 0 {
+				g := c.newBatchGroup(expected)
+				for i := range c.queueGroup {
+					c.queueGroup[i] = g
+				}
+			}
+		} else {
+			c.msg = message{}
+			if err := json.Unmarshal(raw, &c.msg); err != nil {
+				return err
+			}
+			c.queue = []message{c.msg}
+			c.queueGroup = []*batchGroup{nil}
+		}
+	}
+
+	c.msg = c.queue[0]
+	group := c.queueGroup[0]
+	c.queue = c.queue[1:]
+	c.queueGroup = c.queueGroup[1:]
 
 	if c.msg.Method != "" {
 		// request comes to server
@@ -114,6 +288,13 @@ func (c *stratumCodec) ReadHeader(req *rpc2.Request, resp *rpc2.Response) error
 			c.serverRequest.ID = nil
 			req.Seq = c.seq
 			c.mutex.Unlock()
+
+			if group != nil {
+				c.batchMu.Lock()
+				group.order = append(group.order, req.Seq)
+				c.groups[req.Seq] = group
+				c.batchMu.Unlock()
+			}
 		}
 	} else {
 		// response comes to client
@@ -127,27 +308,83 @@ func (c *stratumCodec) ReadHeader(req *rpc2.Request, resp *rpc2.Response) error
 		resp.Error = ""
 		resp.Seq = c.clientResponse.ID
 		if c.clientResponse.Error != nil {
-			x, ok := c.clientResponse.Error.(string)
-			if !ok {
-				// Mining errors.
-				a, ok := c.clientResponse.Error.([]interface{})
-				if !ok {
+			var x string
+			var serr *rpc2.Error
+			switch e := c.clientResponse.Error.(type) {
+			case string:
+				x = e
+			case []interface{}:
+				// Stratum-style [code, message, data] tuple.
+				if len(e) < 2 {
 					return fmt.Errorf("invalid error %v", c.clientResponse.Error)
 				}
-				x = a[1].(string)
+				x, _ = e[1].(string)
+				serr = &rpc2.Error{Message: x}
+				if code, ok := e[0].(float64); ok {
+					serr.Code = int(code)
+				}
+				if len(e) > 2 {
+					serr.Data = e[2]
+				}
+			case map[string]interface{}:
+				// JSON-RPC 2.0 {"code":...,"message":...,"data":...} object.
+				x, _ = e["message"].(string)
+				serr = &rpc2.Error{Message: x}
+				if code, ok := e["code"].(float64); ok {
+					serr.Code = int(code)
+				}
+				serr.Data = e["data"]
+			default:
+				return fmt.Errorf("invalid error %v", c.clientResponse.Error)
 			}
 			if x == "" {
 				x = "unspecified error"
 			}
 			resp.Error = x
+
+			if serr != nil {
+				c.structErrMu.Lock()
+				c.structErrs[c.clientResponse.ID] = serr
+				c.structErrOrder = append(c.structErrOrder, c.clientResponse.ID)
+				for len(c.structErrOrder) > maxStructErrs {
+					delete(c.structErrs, c.structErrOrder[0])
+					c.structErrOrder = c.structErrOrder[1:]
+				}
+				c.structErrMu.Unlock()
+			}
 		}
 	}
 	return nil
 }
 
-var errMissingParams = errors.New("sttratumrpc: request body missing params")
+// StructuredError returns the structured error decoded from the
+// response with the given sequence number, if that response carried one
+// in Stratum array or JSON-RPC 2.0 object form. It returns nil for
+// responses with no error or with a plain string error, and the entry is
+// consumed: a second call for the same seq returns nil.
+func (c *StratumCodec) StructuredError(seq uint64) *rpc2.Error {
+	c.structErrMu.Lock()
+	defer c.structErrMu.Unlock()
+	e := c.structErrs[seq]
+	delete(c.structErrs, seq)
+	return e
+}
+
+// newBatchGroup starts the bookkeeping for one freshly-decoded batch that
+// expects `expected` responses. Its members register themselves, in
+// order, as ReadHeader drains the queue and assigns each a sequence
+// number.
+func (c *StratumCodec) newBatchGroup(expected int) *batchGroup {
+	g := &batchGroup{expected: expected, responses: make(map[uint64]serverResponse)}
+	if c.batchFlushWait > 0 {
+		g.timer = time.AfterFunc(c.batchFlushWait, func() { c.flushBatch(g) })
+	}
+	return g
+}
 
-func (c *stratumCodec) ReadRequestBody(x interface{}) error {
+var errInvalidSeq = errors.New("invalid sequence number in response")
+
+func (c *StratumCodec) ReadRequestBody(x interface{}) error {
 	if x == nil {
 		return nil
 	}
@@ -164,18 +401,19 @@ func (c *stratumCodec) ReadRequestBody(x interface{}) error {
 	return json.Unmarshal(*c.serverRequest.Params, params)
 }
 
-func (c *stratumCodec) ReadResponseBody(x interface{}) error {
+var errMissingParams = errors.New("sttratumrpc: request body missing params")
+
+func (c *StratumCodec) ReadResponseBody(x interface{}) error {
 	if x == nil {
 		return nil
 	}
 	if c.clientResponse.Result == nil {
-		x = c.clientResponse.Result
 		return nil
 	}
 	return json.Unmarshal(*c.clientResponse.Result, x)
 }
 
-func (c *stratumCodec) WriteRequest(r *rpc2.Request, param interface{}) error {
+func (c *StratumCodec) WriteRequest(r *rpc2.Request, param interface{}) error {
 	req := &clientRequest{Method: r.Method}
 	switch param := param.(type) {
 	case []interface{}:
@@ -190,19 +428,21 @@ func (c *stratumCodec) WriteRequest(r *rpc2.Request, param interface{}) error {
 		seq := r.Seq
 		req.ID = &seq
 	}
+	c.encMutex.Lock()
+	defer c.encMutex.Unlock()
 	return c.enc.Encode(req)
 }
 
 var null = json.RawMessage([]byte("null"))
 
-func (c *stratumCodec) WriteResponse(r *rpc2.Response, x interface{}) error {
+func (c *StratumCodec) WriteResponse(r *rpc2.Response, x interface{}) error {
 	var iErr []interface{}
 
 	c.mutex.Lock()
 	b, ok := c.pending[r.Seq]
 	if !ok {
 		c.mutex.Unlock()
-		return errors.New("invalid sequence number in response")
+		return errInvalidSeq
 	}
 	delete(c.pending, r.Seq)
 	c.mutex.Unlock()
@@ -214,17 +454,77 @@ func (c *stratumCodec) WriteResponse(r *rpc2.Response, x interface{}) error {
 	resp := serverResponse{ID: b}
 	if r.Error == "" {
 		resp.Result = x
-	} else {
-		err := json.Unmarshal([]byte(r.Error), &iErr)
-		if err == nil {
-			resp.Error = iErr
+	} else if serr, ok := rpc2.ParseError(r.Error); ok {
+		if c.errorMode == JSONRPC2ErrorMode {
+			resp.Error = rpcError{Code: serr.Code, Message: serr.Message, Data: serr.Data}
 		} else {
-			resp.Error = r.Error
+			resp.Error = []interface{}{serr.Code, serr.Message, serr.Data}
 		}
+	} else if err := json.Unmarshal([]byte(r.Error), &iErr); err == nil {
+		resp.Error = iErr
+	} else {
+		resp.Error = r.Error
 	}
+
+	c.batchMu.Lock()
+	g, inBatch := c.groups[r.Seq]
+	if !inBatch {
+		c.batchMu.Unlock()
+		return c.writeResponse(resp)
+	}
+	g.responses[r.Seq] = resp
+	done := len(g.responses) == g.expected
+	c.batchMu.Unlock()
+
+	if done {
+		return c.flushBatch(g)
+	}
+	return nil
+}
+
+// flushBatch writes the responses collected so far for g, in the
+// original request order, as a single JSON array. Responses still
+// missing (only possible when the flush deadline fires before every
+// sibling has answered) are simply omitted. It is a no-op if the batch
+// was already flushed, which can happen when the deadline races with
+// the last response arriving.
+func (c *StratumCodec) flushBatch(g *batchGroup) error {
+	c.batchMu.Lock()
+	if g.flushed {
+		c.batchMu.Unlock()
+		return nil
+	}
+	g.flushed = true
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	responses := make([]serverResponse, 0, len(g.order))
+	for _, s := range g.order {
+		if resp, ok := g.responses[s]; ok {
+			responses = append(responses, resp)
+		}
+		delete(c.groups, s)
+	}
+	c.batchMu.Unlock()
+
+	if len(responses) == 0 {
+		return nil
+	}
+	return c.writeBatch(responses)
+}
+
+func (c *StratumCodec) writeResponse(resp serverResponse) error {
+	c.encMutex.Lock()
+	defer c.encMutex.Unlock()
 	return c.enc.Encode(resp)
 }
 
-func (c *stratumCodec) Close() error {
+func (c *StratumCodec) writeBatch(responses []serverResponse) error {
+	c.encMutex.Lock()
+	defer c.encMutex.Unlock()
+	return c.enc.Encode(responses)
+}
+
+func (c *StratumCodec) Close() error {
 	return c.c.Close()
 }
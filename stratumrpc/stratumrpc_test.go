@@ -0,0 +1,231 @@
+package stratumrpc
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	rpc2 "github.com/miningmeter/rpc2"
+)
+
+// TestSingleElementBatchFlushesArray guards against the batch group only
+// being created for two or more requests: a batch of exactly one request
+// must still come back as a one-element JSON array, not a bare object.
+func TestSingleElementBatchFlushesArray(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server)
+
+	go client.Write([]byte(`[{"id":1,"method":"foo","params":[]}]`))
+
+	var req rpc2.Request
+	var resp rpc2.Response
+	if err := codec.ReadHeader(&req, &resp); err != nil {
+		t.Fatalf("ReadHeader: %v", err)
+	}
+	if req.Method != "foo" || req.Seq == 0 {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	raw := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := client.Read(buf)
+		raw <- buf[:n]
+	}()
+
+	if err := codec.WriteResponse(&rpc2.Response{Seq: req.Seq}, "bar"); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+
+	var arr []map[string]interface{}
+	if err := json.Unmarshal(<-raw, &arr); err != nil {
+		t.Fatalf("expected a one-element JSON array, got unmarshal error: %v", err)
+	}
+	if len(arr) != 1 || arr[0]["result"] != "bar" {
+		t.Fatalf("unexpected batch response: %+v", arr)
+	}
+}
+
+// TestStructuredErrorsAreBounded guards against structErrs leaking one
+// entry per erroring response for callers that never read it back via
+// StructuredError.
+func TestStructuredErrorsAreBounded(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server)
+
+	const n = maxStructErrs + 50
+	go func() {
+		enc := json.NewEncoder(client)
+		for i := 1; i <= n; i++ {
+			enc.Encode(map[string]interface{}{
+				"id":    i,
+				"error": []interface{}{21, "job not found", nil},
+			})
+		}
+	}()
+
+	for i := 1; i <= n; i++ {
+		var req rpc2.Request
+		var resp rpc2.Response
+		if err := codec.ReadHeader(&req, &resp); err != nil {
+			t.Fatalf("ReadHeader %d: %v", i, err)
+		}
+	}
+
+	if got := len(codec.structErrs); got > maxStructErrs {
+		t.Fatalf("structErrs grew unbounded: %d entries, want <= %d", got, maxStructErrs)
+	}
+	if got := len(codec.structErrOrder); got > maxStructErrs {
+		t.Fatalf("structErrOrder grew unbounded: %d entries, want <= %d", got, maxStructErrs)
+	}
+}
+
+// TestMultiElementBatchCoalescesIntoSingleArray guards the core batch
+// behavior: responses to sibling requests in the same batch must come
+// back as one JSON array, in request order, rather than as separate
+// top-level writes.
+func TestMultiElementBatchCoalescesIntoSingleArray(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server)
+
+	go client.Write([]byte(`[{"id":1,"method":"foo","params":[]},{"id":2,"method":"bar","params":[]}]`))
+
+	var seqs []uint64
+	for i := 0; i < 2; i++ {
+		var req rpc2.Request
+		var resp rpc2.Response
+		if err := codec.ReadHeader(&req, &resp); err != nil {
+			t.Fatalf("ReadHeader %d: %v", i, err)
+		}
+		seqs = append(seqs, req.Seq)
+	}
+
+	raw := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := client.Read(buf)
+		raw <- buf[:n]
+	}()
+
+	if err := codec.WriteResponse(&rpc2.Response{Seq: seqs[0]}, "one"); err != nil {
+		t.Fatalf("WriteResponse 0: %v", err)
+	}
+	if err := codec.WriteResponse(&rpc2.Response{Seq: seqs[1]}, "two"); err != nil {
+		t.Fatalf("WriteResponse 1: %v", err)
+	}
+
+	var arr []map[string]interface{}
+	if err := json.Unmarshal(<-raw, &arr); err != nil {
+		t.Fatalf("expected a single coalesced array, got unmarshal error: %v", err)
+	}
+	if len(arr) != 2 || arr[0]["result"] != "one" || arr[1]["result"] != "two" {
+		t.Fatalf("unexpected batch response: %+v", arr)
+	}
+}
+
+// TestAllNotificationBatchProducesNoArray guards against a batch made
+// up entirely of notifications (no id) ever flushing an empty array:
+// with no sibling expecting a response, there is nothing to coalesce.
+func TestAllNotificationBatchProducesNoArray(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server)
+
+	go client.Write([]byte(`[{"method":"foo","params":[]}]`))
+
+	var req rpc2.Request
+	var resp rpc2.Response
+	if err := codec.ReadHeader(&req, &resp); err != nil {
+		t.Fatalf("ReadHeader: %v", err)
+	}
+	if req.Seq != 0 {
+		t.Fatalf("notification got a sequence number: %+v", req)
+	}
+
+	client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("expected no response to be written for an all-notification batch")
+	}
+}
+
+// TestEmptyBatchRejected guards the protocol-error path for an empty
+// batch, and that it defaults to the Stratum [code, message, data] form.
+func TestEmptyBatchRejected(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server)
+
+	go client.Write([]byte(`[]`))
+
+	raw := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := client.Read(buf)
+		raw <- buf[:n]
+	}()
+
+	var req rpc2.Request
+	var resp rpc2.Response
+	if err := codec.ReadHeader(&req, &resp); err != errEmptyBatch {
+		t.Fatalf("ReadHeader: got %v, want errEmptyBatch", err)
+	}
+
+	var arr []map[string]interface{}
+	if err := json.Unmarshal(<-raw, &arr); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	errArr, ok := arr[0]["error"].([]interface{})
+	if !ok || len(errArr) != 3 {
+		t.Fatalf("expected Stratum [code, message, data] error array, got %+v", arr[0]["error"])
+	}
+}
+
+// TestOversizedBatchRejectedHonorsJSONRPC2ErrorMode guards the
+// oversized-batch rejection against the same wire-shape drift:
+// WithErrorMode(JSONRPC2ErrorMode) must apply to it exactly like it
+// does to a per-request error written by WriteResponse.
+func TestOversizedBatchRejectedHonorsJSONRPC2ErrorMode(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewStratumCodec(server, WithMaxBatchSize(1), WithErrorMode(JSONRPC2ErrorMode))
+
+	go client.Write([]byte(`[{"id":1,"method":"foo","params":[]},{"id":2,"method":"bar","params":[]}]`))
+
+	raw := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := client.Read(buf)
+		raw <- buf[:n]
+	}()
+
+	var req rpc2.Request
+	var resp rpc2.Response
+	if err := codec.ReadHeader(&req, &resp); err != errBatchTooLarge {
+		t.Fatalf("ReadHeader: got %v, want errBatchTooLarge", err)
+	}
+
+	var arr []map[string]interface{}
+	if err := json.Unmarshal(<-raw, &arr); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	errObj, ok := arr[0]["error"].(map[string]interface{})
+	if !ok || errObj["code"] != float64(-32600) {
+		t.Fatalf("expected a JSON-RPC 2.0 error object, got %+v", arr[0]["error"])
+	}
+}
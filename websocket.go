@@ -0,0 +1,87 @@
+package rpc2
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketHandler upgrades incoming HTTP requests to WebSocket and
+// serves each resulting connection as a persistent rpc2 stream, one
+// Codec per connection built by NewCodec. It mirrors HTTPHandler, the
+// difference being a WebSocket connection stays open for many
+// request/response exchanges instead of just one.
+type WebSocketHandler struct {
+	Server   *Server
+	NewCodec func(io.ReadWriteCloser) Codec
+	Upgrader websocket.Upgrader
+}
+
+// ServeHTTP implements http.Handler.
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	h.Server.ServeCodec(h.NewCodec(&wsConn{conn: conn}))
+}
+
+// DialWebSocket connects to url over WebSocket and returns a Client
+// wired through newCodec, so Call, Notify and Handle work exactly as
+// they do over a raw TCP connection.
+func DialWebSocket(url string, newCodec func(io.ReadWriteCloser) Codec) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithCodec(newCodec(&wsConn{conn: conn})), nil
+}
+
+// wsConn adapts a *websocket.Conn into the io.ReadWriteCloser a Codec
+// expects: Read streams the payload of successive text messages as one
+// contiguous byte stream, and Write sends one text message per call.
+// Ping/pong and close frames never reach Read; gorilla/websocket answers
+// pings and surfaces a close frame as the error returned from
+// NextReader, which Read passes straight through to the Codec's decoder
+// so the connection tears down the same way a closed TCP socket would.
+type wsConn struct {
+	conn *websocket.Conn
+	r    io.Reader
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.r == nil {
+			_, r, err := c.conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.r = r
+		}
+		n, err := c.r.Read(p)
+		if err == io.EOF {
+			c.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.conn.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	deadline := time.Now().Add(time.Second)
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	return c.conn.Close()
+}